@@ -0,0 +1,87 @@
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+func (opt *Options) dialTimeout() time.Duration {
+	if opt.DialTimeout <= 0 {
+		return 5 * time.Second
+	}
+	return opt.DialTimeout
+}
+
+func (opt *Options) network() string {
+	if opt.Network == "" {
+		return "tcp"
+	}
+	return opt.Network
+}
+
+// Client is a single-node Redis client: the connPool-backed counterpart
+// of ClusterClient, FailoverClient and Ring for a plain, fixed-address
+// server. Build one from a connection string with ParseURL, or from
+// Options directly.
+type Client struct {
+	connPool *connPool
+}
+
+// NewClient dials opt.Addr lazily, via opt's pooling and TLS settings.
+func NewClient(opt *Options) *Client {
+	dialer := &net.Dialer{Timeout: opt.dialTimeout()}
+
+	return &Client{
+		connPool: newConnPool(connPoolOptions{
+			Dial: func() (net.Conn, error) {
+				if opt.TLSConfig != nil {
+					return tls.DialWithDialer(dialer, opt.network(), opt.Addr, opt.TLSConfig)
+				}
+				return dialer.Dial(opt.network(), opt.Addr)
+			},
+			MaxSize:      opt.PoolSize,
+			MinIdleConns: opt.MinIdleConns,
+			ReadTimeout:  opt.ReadTimeout,
+			WriteTimeout: opt.WriteTimeout,
+			IdleTimeout:  opt.IdleTimeout,
+			MaxConnAge:   opt.MaxConnAge,
+			PoolTimeout:  opt.PoolTimeout,
+		}),
+	}
+}
+
+// Do sends a single command, removing the conn from the pool on a
+// transport-level failure and returning it otherwise.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("redis: Do requires at least a command name")
+	}
+
+	cn, _, err := c.connPool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeCmd(cn, args...); err != nil {
+		c.connPool.Remove(cn)
+		return nil, err
+	}
+
+	v, err := readReply(cn)
+	if err != nil {
+		if _, ok := err.(redisError); !ok {
+			c.connPool.Remove(cn)
+			return nil, err
+		}
+	}
+
+	c.connPool.Put(cn)
+	return v, err
+}
+
+func (c *Client) Close() error {
+	return c.connPool.Close()
+}
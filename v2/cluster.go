@@ -0,0 +1,470 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ClusterOptions configures a ClusterClient. It mirrors Options but takes
+// multiple seed addresses since the rest of the node list is discovered
+// from the cluster itself via CLUSTER SLOTS.
+type ClusterOptions struct {
+	Addrs []string
+
+	// MaxRedirects is the maximum number of MOVED/ASK redirects to
+	// follow for a single command. Defaults to 3.
+	MaxRedirects int
+
+	// ReadOnly sends each command for a slot that has replicas to a
+	// random one of those replicas instead of the master. Commands are
+	// not told apart as reads or writes, and READONLY is not sent on the
+	// replica connection.
+	ReadOnly bool
+
+	PoolSize     int
+	MinIdleConns int
+	MaxConnAge   time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+}
+
+func (opt *ClusterOptions) maxRedirects() int {
+	if opt.MaxRedirects < 1 {
+		return 3
+	}
+	return opt.MaxRedirects
+}
+
+//------------------------------------------------------------------------------
+
+const numSlots = 16384
+
+// clusterSlot is the node ownership of one slot, as reported by
+// CLUSTER SLOTS.
+type clusterSlot struct {
+	master   string
+	replicas []string
+}
+
+//------------------------------------------------------------------------------
+
+// ClusterClient talks to a Redis Cluster. It keeps one connPool per node
+// address and an atomically-replaceable slot table, so commands are sent
+// straight to the owning node instead of bouncing through a proxy.
+type ClusterClient struct {
+	opt *ClusterOptions
+
+	mu    sync.RWMutex
+	nodes map[string]*connPool
+
+	slots atomic.Value // []clusterSlot, len == numSlots
+}
+
+// NewClusterClient connects to a Redis Cluster and loads its initial slot
+// table from one seed picked at random from opt.Addrs. It returns an error
+// if CLUSTER SLOTS can't be fetched from that seed (the other seeds are
+// not tried), since a client with an empty slot table would otherwise
+// fail every command with a confusing "missing address" error instead.
+func NewClusterClient(opt *ClusterOptions) (*ClusterClient, error) {
+	c := &ClusterClient{
+		opt:   opt,
+		nodes: make(map[string]*connPool),
+	}
+	c.slots.Store(make([]clusterSlot, numSlots))
+	if err := c.ReloadSlots(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ReloadSlots re-fetches the slot table from any known node via
+// CLUSTER SLOTS and atomically swaps it in.
+func (c *ClusterClient) ReloadSlots() error {
+	addr, err := c.anyAddr()
+	if err != nil {
+		return err
+	}
+
+	pool, err := c.nodePool(addr)
+	if err != nil {
+		return err
+	}
+
+	cn, _, err := pool.Get(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if err := writeCmd(cn, "CLUSTER", "SLOTS"); err != nil {
+		pool.Remove(cn)
+		return err
+	}
+	reply, err := readReply(cn)
+	if err != nil {
+		pool.Remove(cn)
+		return err
+	}
+	pool.Put(cn)
+
+	rows, ok := reply.([]interface{})
+	if !ok {
+		return fmt.Errorf("redis: unexpected CLUSTER SLOTS reply: %v", reply)
+	}
+
+	table := make([]clusterSlot, numSlots)
+	for _, row := range rows {
+		fields, ok := row.([]interface{})
+		if !ok || len(fields) < 3 {
+			continue
+		}
+		start, err := toInt(fields[0])
+		if err != nil {
+			continue
+		}
+		end, err := toInt(fields[1])
+		if err != nil {
+			continue
+		}
+
+		master, ok := nodeAddr(fields[2])
+		if !ok {
+			continue
+		}
+
+		var replicas []string
+		for _, f := range fields[3:] {
+			if addr, ok := nodeAddr(f); ok {
+				replicas = append(replicas, addr)
+			}
+		}
+
+		slot := clusterSlot{master: master, replicas: replicas}
+		for s := start; s <= end && s < numSlots; s++ {
+			table[s] = slot
+		}
+	}
+
+	c.slots.Store(table)
+	return nil
+}
+
+func nodeAddr(v interface{}) (string, bool) {
+	fields, ok := v.([]interface{})
+	if !ok || len(fields) < 2 {
+		return "", false
+	}
+	host, ok := fields[0].(string)
+	if !ok {
+		return "", false
+	}
+	port, err := toInt(fields[1])
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("%s:%d", host, port), true
+}
+
+func toInt(v interface{}) (int, error) {
+	switch v := v.(type) {
+	case int64:
+		return int(v), nil
+	case string:
+		var n int
+		_, err := fmt.Sscanf(v, "%d", &n)
+		return n, err
+	default:
+		return 0, fmt.Errorf("redis: not an integer: %v", v)
+	}
+}
+
+func (c *ClusterClient) anyAddr() (string, error) {
+	c.mu.RLock()
+	for addr := range c.nodes {
+		c.mu.RUnlock()
+		return addr, nil
+	}
+	c.mu.RUnlock()
+
+	if len(c.opt.Addrs) == 0 {
+		return "", fmt.Errorf("redis: no cluster addresses configured")
+	}
+	return c.opt.Addrs[rand.Intn(len(c.opt.Addrs))], nil
+}
+
+func (c *ClusterClient) nodePool(addr string) (*connPool, error) {
+	c.mu.RLock()
+	pool, ok := c.nodes[addr]
+	c.mu.RUnlock()
+	if ok {
+		return pool, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pool, ok := c.nodes[addr]; ok {
+		return pool, nil
+	}
+
+	pool = newConnPool(connPoolOptions{
+		Dial: func() (net.Conn, error) {
+			return net.DialTimeout("tcp", addr, 5*time.Second)
+		},
+		MaxSize:      c.opt.PoolSize,
+		MinIdleConns: c.opt.MinIdleConns,
+		ReadTimeout:  c.opt.ReadTimeout,
+		WriteTimeout: c.opt.WriteTimeout,
+		IdleTimeout:  c.opt.IdleTimeout,
+		MaxConnAge:   c.opt.MaxConnAge,
+	})
+	c.nodes[addr] = pool
+	return pool, nil
+}
+
+func (c *ClusterClient) slotAddr(slot int) string {
+	table := c.slots.Load().([]clusterSlot)
+	s := table[slot]
+	if c.opt.ReadOnly && len(s.replicas) > 0 {
+		return s.replicas[rand.Intn(len(s.replicas))]
+	}
+	return s.master
+}
+
+// hashtagKey returns the part of key that should be hashed: when key
+// contains a non-empty {...}, only the part inside the braces, so
+// related keys can be pinned to the same slot or shard.
+func hashtagKey(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+	return key
+}
+
+// hashSlot computes the Redis Cluster slot for key.
+func hashSlot(key string) int {
+	return int(crc16sum(hashtagKey(key))) % numSlots
+}
+
+var crc16Table [256]uint16
+
+func init() {
+	const poly = 0x1021
+	for i := 0; i < 256; i++ {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = (crc << 1) ^ poly
+			} else {
+				crc <<= 1
+			}
+		}
+		crc16Table[i] = crc
+	}
+}
+
+func crc16sum(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc = (crc << 8) ^ crc16Table[byte(crc>>8)^s[i]]
+	}
+	return crc
+}
+
+// multiKeyCommands describes, for commands that address more than one
+// key, where those keys sit in args: the index of the first key and the
+// stride to the next one. Commands not listed here are assumed to take
+// a single key at args[1], the common case.
+var multiKeyCommands = map[string]struct{ start, step int }{
+	"MGET":   {1, 1},
+	"DEL":    {1, 1},
+	"UNLINK": {1, 1},
+	"EXISTS": {1, 1},
+	"TOUCH":  {1, 1},
+	"WATCH":  {1, 1},
+	"MSET":   {1, 2},
+	"MSETNX": {1, 2},
+}
+
+// commandKeys returns every key a command addresses, per
+// multiKeyCommands, or just args[1] for commands not listed there.
+func commandKeys(args []string) []string {
+	if len(args) < 2 {
+		return nil
+	}
+	spec, ok := multiKeyCommands[strings.ToUpper(args[0])]
+	if !ok {
+		return []string{args[1]}
+	}
+	var keys []string
+	for i := spec.start; i < len(args); i += spec.step {
+		keys = append(keys, args[i])
+	}
+	return keys
+}
+
+// commandSlot returns the single slot a command's keys all hash to, or
+// an error if the command has no key or its keys span more than one
+// slot — Redis Cluster can't execute a multi-key command atomically
+// across nodes, so this is rejected client-side instead of round-
+// tripping to a node just to get back CROSSSLOT.
+func commandSlot(args []string) (int, error) {
+	keys := commandKeys(args)
+	if len(keys) == 0 {
+		return 0, fmt.Errorf("redis: cluster command %q requires a key", args)
+	}
+
+	slot := hashSlot(keys[0])
+	for _, key := range keys[1:] {
+		if s := hashSlot(key); s != slot {
+			return 0, fmt.Errorf("redis: cluster command %q has keys spanning multiple slots (%d and %d)", args, slot, s)
+		}
+	}
+	return slot, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Do sends a single command to the node owning args' key, following
+// MOVED/ASK redirects up to MaxRedirects times.
+func (c *ClusterClient) Do(args ...string) (interface{}, error) {
+	slot, err := commandSlot(args)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := c.slotAddr(slot)
+	var ask bool
+	var lastErr error
+
+	for attempt := 0; attempt <= c.opt.maxRedirects(); attempt++ {
+		pool, err := c.nodePool(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		cn, _, err := pool.Get(context.Background())
+		if err != nil {
+			return nil, err
+		}
+
+		if ask {
+			if err := writeCmd(cn, "ASKING"); err != nil {
+				pool.Remove(cn)
+				return nil, err
+			}
+			if _, err := readReply(cn); err != nil {
+				pool.Remove(cn)
+				return nil, err
+			}
+		}
+
+		if err := writeCmd(cn, args...); err != nil {
+			pool.Remove(cn)
+			return nil, err
+		}
+
+		v, err := readReply(cn)
+		if err != nil {
+			rerr, ok := err.(redisError)
+			if !ok {
+				// Transport-level failure: the conn itself is suspect.
+				pool.Remove(cn)
+				return nil, err
+			}
+
+			if movedAddr, ok := parseRedirect("MOVED", rerr); ok {
+				pool.Put(cn)
+				addr, ask, lastErr = movedAddr, false, err
+				go c.ReloadSlots()
+				continue
+			}
+			if askAddr, ok := parseRedirect("ASK", rerr); ok {
+				pool.Put(cn)
+				addr, ask, lastErr = askAddr, true, err
+				continue
+			}
+
+			// An ordinary application-level error (WRONGTYPE, ERR,
+			// CROSSSLOT, ...): the conn is still healthy.
+			pool.Put(cn)
+			return nil, err
+		}
+
+		pool.Put(cn)
+		return v, nil
+	}
+
+	return nil, lastErr
+}
+
+func parseRedirect(kind string, err redisError) (addr string, ok bool) {
+	fields := strings.Fields(string(err))
+	if len(fields) != 3 || fields[0] != kind {
+		return "", false
+	}
+	return fields[2], true
+}
+
+func (c *ClusterClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for addr, pool := range c.nodes {
+		if err := pool.Close(); err != nil {
+			return err
+		}
+		delete(c.nodes, addr)
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// ClusterPipeline batches commands that must all land on the same node.
+// Queuing a key that hashes to a different slot than the ones already
+// queued fails fast, since Redis Cluster refuses cross-slot multi-key
+// operations.
+type ClusterPipeline struct {
+	c    *ClusterClient
+	slot int
+	cmds [][]string
+}
+
+func (c *ClusterClient) Pipeline() *ClusterPipeline {
+	return &ClusterPipeline{c: c, slot: -1}
+}
+
+func (p *ClusterPipeline) Queue(args ...string) error {
+	slot, err := commandSlot(args)
+	if err != nil {
+		return err
+	}
+
+	if p.slot == -1 {
+		p.slot = slot
+	} else if slot != p.slot {
+		return fmt.Errorf("redis: cluster pipeline keys span multiple slots (%d and %d)", p.slot, slot)
+	}
+
+	p.cmds = append(p.cmds, args)
+	return nil
+}
+
+func (p *ClusterPipeline) Exec() ([]interface{}, error) {
+	replies := make([]interface{}, len(p.cmds))
+	for i, args := range p.cmds {
+		v, err := p.c.Do(args...)
+		if err != nil {
+			return replies, err
+		}
+		replies[i] = v
+	}
+	return replies, nil
+}
@@ -0,0 +1,127 @@
+package redis
+
+import "testing"
+
+func TestHashtagKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"foo", "foo"},
+		{"{user1000}.following", "user1000"},
+		{"foo{}bar", "foo{}bar"},
+		{"{}bar", "{}bar"},
+		{"{user1000}.followers{ignored}", "user1000"},
+		{"foo{bar", "foo{bar"},
+	}
+	for _, tt := range tests {
+		if got := hashtagKey(tt.key); got != tt.want {
+			t.Errorf("hashtagKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestHashSlot(t *testing.T) {
+	// Known CRC16(CCITT-XMODEM) slot assignments, cross-checked against
+	// the reference Redis Cluster implementation.
+	tests := []struct {
+		key  string
+		slot int
+	}{
+		{"123456789", 12739},
+		{"foo", 12182},
+	}
+	for _, tt := range tests {
+		if got := hashSlot(tt.key); got != tt.slot {
+			t.Errorf("hashSlot(%q) = %d, want %d", tt.key, got, tt.slot)
+		}
+	}
+}
+
+func TestHashSlotHashtagConsistency(t *testing.T) {
+	a := hashSlot("{user1000}.following")
+	b := hashSlot("{user1000}.followers")
+	if a != b {
+		t.Errorf("keys sharing a hashtag landed in different slots: %d != %d", a, b)
+	}
+}
+
+func TestCrc16sum(t *testing.T) {
+	// Reference vectors from the CRC16/XMODEM test suite.
+	tests := []struct {
+		s    string
+		want uint16
+	}{
+		{"", 0x0000},
+		{"123456789", 0x31c3},
+	}
+	for _, tt := range tests {
+		if got := crc16sum(tt.s); got != tt.want {
+			t.Errorf("crc16sum(%q) = %#04x, want %#04x", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestCommandKeys(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"GET", "k1"}, []string{"k1"}},
+		{[]string{"MGET", "k1", "k2", "k3"}, []string{"k1", "k2", "k3"}},
+		{[]string{"DEL", "k1", "k2"}, []string{"k1", "k2"}},
+		{[]string{"MSET", "k1", "v1", "k2", "v2"}, []string{"k1", "k2"}},
+		{[]string{"PING"}, nil},
+	}
+	for _, tt := range tests {
+		got := commandKeys(tt.args)
+		if len(got) != len(tt.want) {
+			t.Errorf("commandKeys(%v) = %v, want %v", tt.args, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("commandKeys(%v) = %v, want %v", tt.args, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCommandSlot(t *testing.T) {
+	if _, err := commandSlot([]string{"PING"}); err == nil {
+		t.Error("commandSlot(PING) = nil error, want error for missing key")
+	}
+
+	if _, err := commandSlot([]string{"SET", "{user1}.a", "v"}); err != nil {
+		t.Errorf("commandSlot(single key) = %v, want no error", err)
+	}
+
+	if _, err := commandSlot([]string{"MSET", "{user1}.a", "v1", "{user1}.b", "v2"}); err != nil {
+		t.Errorf("commandSlot(same-slot MSET) = %v, want no error", err)
+	}
+
+	if _, err := commandSlot([]string{"MSET", "{user1}.a", "v1", "{user2}.b", "v2"}); err == nil {
+		t.Error("commandSlot(cross-slot MSET) = nil error, want CROSSSLOT-style error")
+	}
+}
+
+func TestParseRedirect(t *testing.T) {
+	tests := []struct {
+		kind   string
+		err    redisError
+		addr   string
+		wantOK bool
+	}{
+		{"MOVED", redisError("MOVED 3999 127.0.0.1:6381"), "127.0.0.1:6381", true},
+		{"ASK", redisError("ASK 3999 127.0.0.1:6381"), "127.0.0.1:6381", true},
+		{"MOVED", redisError("ASK 3999 127.0.0.1:6381"), "", false},
+		{"MOVED", redisError("WRONGTYPE Operation against a key"), "", false},
+	}
+	for _, tt := range tests {
+		addr, ok := parseRedirect(tt.kind, tt.err)
+		if ok != tt.wantOK || addr != tt.addr {
+			t.Errorf("parseRedirect(%q, %q) = (%q, %v), want (%q, %v)", tt.kind, tt.err, addr, ok, tt.addr, tt.wantOK)
+		}
+	}
+}
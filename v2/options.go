@@ -0,0 +1,27 @@
+package redis
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+// Options configures a single-node client's connPool and dial target.
+type Options struct {
+	Network string
+	Addr    string
+
+	Password string
+	DB       int64
+
+	PoolSize     int
+	MinIdleConns int
+	MaxConnAge   time.Duration
+
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	PoolTimeout  time.Duration
+
+	TLSConfig *tls.Config
+}
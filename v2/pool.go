@@ -1,16 +1,23 @@
 package redis
 
 import (
-	"container/list"
+	"context"
+	"errors"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/vmihailenco/bufio"
 )
 
+// ErrPoolTimeout is returned by Get when PoolTimeout elapses before a
+// connection becomes available, so callers can tell a saturated pool
+// apart from a dial failure.
+var ErrPoolTimeout = errors.New("redis: connection pool timeout")
+
 type pool interface {
-	Get() (*conn, bool, error)
+	Get(ctx context.Context) (*conn, bool, error)
 	Put(*conn) error
 	Remove(*conn) error
 	Len() int
@@ -19,11 +26,21 @@ type pool interface {
 
 //------------------------------------------------------------------------------
 
+// reader is the subset of *bufio.Reader that the RESP decoder in
+// proto.go and conn.IsHealthy use.
+type reader interface {
+	Read(b []byte) (int, error)
+	ReadByte() (byte, error)
+	ReadString(delim byte) (string, error)
+	Peek(n int) ([]byte, error)
+}
+
 type conn struct {
 	Cn net.Conn
 	Rd reader
 
-	UsedAt time.Time
+	UsedAt    time.Time
+	createdAt time.Time
 
 	readTimeout, writeTimeout time.Duration
 }
@@ -32,6 +49,8 @@ func newConn(netcn net.Conn, readTimeout, writeTimeout time.Duration) *conn {
 	cn := &conn{
 		Cn: netcn,
 
+		createdAt: time.Now(),
+
 		readTimeout:  readTimeout,
 		writeTimeout: writeTimeout,
 	}
@@ -53,80 +72,319 @@ func (cn *conn) Write(b []byte) (int, error) {
 	return cn.Cn.Write(b)
 }
 
+// IsHealthy cheaply probes whether cn is still alive: the protocol
+// guarantees the server stays silent between replies, so any byte (or
+// EOF) available for an immediate, non-blocking peek means the
+// connection was closed or desynced server-side.
+func (cn *conn) IsHealthy() bool {
+	if err := cn.Cn.SetReadDeadline(time.Now()); err != nil {
+		return false
+	}
+
+	_, err := cn.Rd.Peek(1)
+	cn.Cn.SetReadDeadline(time.Time{})
+
+	if err == nil {
+		return false
+	}
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
 //------------------------------------------------------------------------------
 
 type connPool struct {
 	dial  func() (net.Conn, error)
 	close func(net.Conn) error
 
-	cond  *sync.Cond
-	conns *list.List
+	// queue is a buffered semaphore: one token is held for the
+	// lifetime of every dialed conn, whether idle or checked out, so
+	// its length is always the number of live conns and sends block
+	// once maxSize is reached.
+	queue chan struct{}
+	idle  chan *conn
 
+	poolTimeout               time.Duration
 	readTimeout, writeTimeout time.Duration
 
-	size, maxSize int
-	idleTimeout   time.Duration
-}
+	mu      sync.Mutex
+	size    int
+	maxSize int
 
-func newConnPool(
-	dial func() (net.Conn, error),
-	close func(net.Conn) error,
-	maxSize int,
-	readTimeout, writeTimeout, idleTimeout time.Duration,
-) *connPool {
-	return &connPool{
-		dial:  dial,
-		close: close,
+	idleTimeout  time.Duration
+	maxConnAge   time.Duration
+	minIdleConns int
 
-		cond:  sync.NewCond(&sync.Mutex{}),
-		conns: list.New(),
+	testOnBorrow func(cn *conn, now time.Time) error
 
-		maxSize: maxSize,
+	stopReaper chan struct{}
+	closeOnce  sync.Once
 
-		readTimeout:  readTimeout,
-		writeTimeout: writeTimeout,
-		idleTimeout:  idleTimeout,
+	hits, misses, timeouts, staleConns uint32
+}
+
+// PoolStats summarizes a connPool's activity since it was created.
+type PoolStats struct {
+	Hits       uint32
+	Misses     uint32
+	Timeouts   uint32
+	TotalConns uint32
+	IdleConns  uint32
+	StaleConns uint32
+}
+
+func (p *connPool) Stats() *PoolStats {
+	return &PoolStats{
+		Hits:       atomic.LoadUint32(&p.hits),
+		Misses:     atomic.LoadUint32(&p.misses),
+		Timeouts:   atomic.LoadUint32(&p.timeouts),
+		TotalConns: uint32(p.Size()),
+		IdleConns:  uint32(len(p.idle)),
+		StaleConns: atomic.LoadUint32(&p.staleConns),
 	}
 }
 
-func (p *connPool) Get() (*conn, bool, error) {
-	defer p.cond.L.Unlock()
-	p.cond.L.Lock()
+// connPoolOptions bundles newConnPool's parameters. It grew too many
+// positional args (size, three timeouts, min-idle floor, max age,
+// borrow hook) to keep threading through constructor calls by hand.
+type connPoolOptions struct {
+	Dial  func() (net.Conn, error)
+	Close func(net.Conn) error
+
+	MaxSize      int
+	MinIdleConns int
+
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	MaxConnAge   time.Duration
+	PoolTimeout  time.Duration
+
+	// TestOnBorrow, if set, is called on every idle conn Get is about to
+	// reuse (e.g. to PING it once time.Since(cn.UsedAt) crosses a
+	// threshold); a non-nil error discards the conn and tries again.
+	// Freshly dialed conns are not passed to it.
+	TestOnBorrow func(cn *conn, now time.Time) error
+}
+
+func newConnPool(opt connPoolOptions) *connPool {
+	p := &connPool{
+		dial:  opt.Dial,
+		close: opt.Close,
+
+		queue: make(chan struct{}, opt.MaxSize),
+		idle:  make(chan *conn, opt.MaxSize),
+
+		maxSize: opt.MaxSize,
 
-	for p.conns.Len() == 0 && p.size >= p.maxSize {
-		p.cond.Wait()
+		poolTimeout:  opt.PoolTimeout,
+		readTimeout:  opt.ReadTimeout,
+		writeTimeout: opt.WriteTimeout,
+		idleTimeout:  opt.IdleTimeout,
+		maxConnAge:   opt.MaxConnAge,
+		minIdleConns: opt.MinIdleConns,
+		testOnBorrow: opt.TestOnBorrow,
+
+		stopReaper: make(chan struct{}),
 	}
 
-	if p.idleTimeout > 0 {
-		for e := p.conns.Front(); e != nil; e = e.Next() {
-			cn := e.Value.(*conn)
-			if time.Since(cn.UsedAt) > p.idleTimeout {
-				p.conns.Remove(e)
-			}
-		}
+	if p.minIdleConns > 0 {
+		go p.warmupIdleConns()
+	}
+	if p.idleTimeout > 0 || p.maxConnAge > 0 || p.minIdleConns > 0 {
+		go p.reaper(p.reapFrequency())
+	}
+
+	return p
+}
+
+func (p *connPool) reapFrequency() time.Duration {
+	if p.idleTimeout > 0 && p.idleTimeout < time.Second {
+		return p.idleTimeout
 	}
+	return time.Second
+}
+
+// warmupIdleConns dials connections until the pool holds at least
+// minIdleConns, stopping early if the pool is already at maxSize.
+//
+// It checks p.stopReaper both before acquiring a queue slot and again
+// after dialing, so a Close() that races with a slow dial doesn't leak
+// a connection into a pool that already considers itself closed: once
+// Close() has drained p.idle, nothing else ever reads from it.
+func (p *connPool) warmupIdleConns() {
+	for p.Size() < p.minIdleConns {
+		select {
+		case <-p.stopReaper:
+			return
+		default:
+		}
+
+		select {
+		case p.queue <- struct{}{}:
+		default:
+			return
+		}
 
-	if p.conns.Len() == 0 {
 		rw, err := p.dial()
 		if err != nil {
-			return nil, false, err
+			<-p.queue
+			return
 		}
 
+		cn := newConn(rw, p.readTimeout, p.writeTimeout)
+		cn.UsedAt = time.Now()
+
+		select {
+		case <-p.stopReaper:
+			p.closeConn(cn)
+			<-p.queue
+			return
+		default:
+		}
+
+		p.mu.Lock()
 		p.size++
-		return newConn(rw, p.readTimeout, p.writeTimeout), true, nil
+		p.mu.Unlock()
+
+		p.idle <- cn
+	}
+}
+
+// reaper periodically prunes idle conns that exceeded idleTimeout or
+// maxConnAge, then tops the pool back up to minIdleConns.
+func (p *connPool) reaper(frequency time.Duration) {
+	ticker := time.NewTicker(frequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.reapStaleConns()
+			if p.minIdleConns > 0 {
+				p.warmupIdleConns()
+			}
+		case <-p.stopReaper:
+			return
+		}
+	}
+}
+
+func (p *connPool) reapStaleConns() {
+	n := len(p.idle)
+	for i := 0; i < n; i++ {
+		select {
+		case cn := <-p.idle:
+			if p.isStale(cn) {
+				p.closeConn(cn)
+				<-p.queue
+				p.mu.Lock()
+				p.size--
+				p.mu.Unlock()
+				atomic.AddUint32(&p.staleConns, 1)
+			} else {
+				p.idle <- cn
+			}
+		default:
+			return
+		}
 	}
+}
 
-	elem := p.conns.Front()
-	p.conns.Remove(elem)
-	return elem.Value.(*conn), false, nil
+func (p *connPool) isStale(cn *conn) bool {
+	if p.idleTimeout > 0 && time.Since(cn.UsedAt) > p.idleTimeout {
+		return true
+	}
+	if p.maxConnAge > 0 && time.Since(cn.createdAt) > p.maxConnAge {
+		return true
+	}
+	return false
 }
 
+// checkoutIdle validates a conn popped off p.idle against isStale and
+// TestOnBorrow. It reports ok=false if the conn was discarded (its
+// queue token and size already released), in which case the caller
+// should go back for another.
+func (p *connPool) checkoutIdle(cn *conn) (*conn, bool) {
+	discard := p.isStale(cn)
+	if !discard && p.testOnBorrow != nil {
+		discard = p.testOnBorrow(cn, time.Now()) != nil
+	}
+	if discard {
+		p.closeConn(cn)
+		<-p.queue
+		p.mu.Lock()
+		p.size--
+		p.mu.Unlock()
+		atomic.AddUint32(&p.staleConns, 1)
+		return nil, false
+	}
+
+	atomic.AddUint32(&p.hits, 1)
+	return cn, true
+}
+
+// Get returns an idle conn if one is available, otherwise dials a new
+// one if the pool has room, blocking until either happens, ctx is
+// canceled, or PoolTimeout elapses.
+func (p *connPool) Get(ctx context.Context) (*conn, bool, error) {
+	var timeoutCh <-chan time.Time
+	if p.poolTimeout > 0 {
+		timer := time.NewTimer(p.poolTimeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	for {
+		// Prefer an already-idle conn over dialing a fresh one: a
+		// single multi-way select below would pick between the two
+		// uniformly at random whenever both are ready, defeating the
+		// point of pooling.
+		select {
+		case cn := <-p.idle:
+			if cn, ok := p.checkoutIdle(cn); ok {
+				return cn, false, nil
+			}
+			continue
+		default:
+		}
+
+		select {
+		case cn := <-p.idle:
+			if cn, ok := p.checkoutIdle(cn); ok {
+				return cn, false, nil
+			}
+			continue
+		case p.queue <- struct{}{}:
+			rw, err := p.dial()
+			if err != nil {
+				<-p.queue
+				return nil, false, err
+			}
+			p.mu.Lock()
+			p.size++
+			p.mu.Unlock()
+			atomic.AddUint32(&p.misses, 1)
+			return newConn(rw, p.readTimeout, p.writeTimeout), true, nil
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-timeoutCh:
+			atomic.AddUint32(&p.timeouts, 1)
+			return nil, false, ErrPoolTimeout
+		}
+	}
+}
+
+// Put returns cn to the idle pool, unless it's found to be half-closed
+// (server restart, a middlebox killing an idle TCP connection), in
+// which case it's discarded so the next Get doesn't hand a dead
+// connection to its caller.
 func (p *connPool) Put(cn *conn) error {
-	p.cond.L.Lock()
+	if !cn.IsHealthy() {
+		return p.Remove(cn)
+	}
 	cn.UsedAt = time.Now()
-	p.conns.PushFront(cn)
-	p.cond.Signal()
-	p.cond.L.Unlock()
+	p.idle <- cn
 	return nil
 }
 
@@ -135,42 +393,47 @@ func (p *connPool) Remove(cn *conn) error {
 	if cn != nil {
 		err = p.closeConn(cn)
 	}
-	p.cond.L.Lock()
+	<-p.queue
+	p.mu.Lock()
 	p.size--
-	p.cond.Signal()
-	p.cond.L.Unlock()
+	p.mu.Unlock()
 	return err
 }
 
 func (p *connPool) Len() int {
-	return p.conns.Len()
+	return len(p.idle)
 }
 
 func (p *connPool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	return p.size
 }
 
 func (p *connPool) Close() error {
-	defer p.cond.L.Unlock()
-	p.cond.L.Lock()
+	p.closeOnce.Do(func() { close(p.stopReaper) })
 
-	for e := p.conns.Front(); e != nil; e = e.Next() {
-		if err := p.closeConn(e.Value.(*conn)); err != nil {
-			return err
+	for {
+		select {
+		case cn := <-p.idle:
+			if err := p.closeConn(cn); err != nil {
+				return err
+			}
+			<-p.queue
+		default:
+			p.mu.Lock()
+			p.size = 0
+			p.mu.Unlock()
+			return nil
 		}
 	}
-	p.conns.Init()
-	p.size = 0
-
-	return nil
 }
 
 func (p *connPool) closeConn(cn *conn) error {
 	if p.close != nil {
 		return p.close(cn.Cn)
-	} else {
-		return cn.Cn.Close()
 	}
+	return cn.Cn.Close()
 }
 
 //------------------------------------------------------------------------------
@@ -191,7 +454,7 @@ func newSingleConnPool(pool pool, cn *conn, reusable bool) *singleConnPool {
 	}
 }
 
-func (p *singleConnPool) Get() (*conn, bool, error) {
+func (p *singleConnPool) Get(ctx context.Context) (*conn, bool, error) {
 	p.l.RLock()
 	if p.cn != nil {
 		p.l.RUnlock()
@@ -202,7 +465,7 @@ func (p *singleConnPool) Get() (*conn, bool, error) {
 	defer p.l.Unlock()
 	p.l.Lock()
 
-	cn, isNew, err := p.pool.Get()
+	cn, isNew, err := p.pool.Get(ctx)
 	if err != nil {
 		return nil, false, err
 	}
@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// newPipeDialer returns a Dial func that hands out one end of an
+// in-memory net.Pipe. The other end is left open and silent (never
+// written to) so IsHealthy's read-deadline probe sees a timeout, not a
+// closed-pipe error, matching a real idle server connection; t closes
+// the server end when the test exits.
+func newPipeDialer(t *testing.T) func() (net.Conn, error) {
+	return func() (net.Conn, error) {
+		client, server := net.Pipe()
+		t.Cleanup(func() { server.Close() })
+		return client, nil
+	}
+}
+
+func TestConnPoolGetBlocksUntilContextCanceled(t *testing.T) {
+	p := newConnPool(connPoolOptions{
+		Dial:    newPipeDialer(t),
+		MaxSize: 1,
+	})
+	defer p.Close()
+
+	cn, _, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("first Get: %v", err)
+	}
+	_ = cn // pool is now at MaxSize with no idle conn
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := p.Get(ctx)
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Get returned %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Get did not return after context cancellation")
+	}
+}
+
+func TestConnPoolGetTimesOut(t *testing.T) {
+	p := newConnPool(connPoolOptions{
+		Dial:        newPipeDialer(t),
+		MaxSize:     1,
+		PoolTimeout: 20 * time.Millisecond,
+	})
+	defer p.Close()
+
+	if _, _, err := p.Get(context.Background()); err != nil {
+		t.Fatalf("first Get: %v", err)
+	}
+
+	_, _, err := p.Get(context.Background())
+	if err != ErrPoolTimeout {
+		t.Errorf("second Get = %v, want ErrPoolTimeout", err)
+	}
+	if got := p.Stats().Timeouts; got != 1 {
+		t.Errorf("Stats().Timeouts = %d, want 1", got)
+	}
+}
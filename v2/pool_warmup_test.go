@@ -0,0 +1,68 @@
+package redis
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConnPoolReapsStaleIdleConns(t *testing.T) {
+	p := newConnPool(connPoolOptions{
+		Dial:        newPipeDialer(t),
+		MaxSize:     2,
+		IdleTimeout: 10 * time.Millisecond,
+	})
+	defer p.Close()
+
+	cn, _, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := p.Put(cn); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().StaleConns == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if size := p.Size(); size != 0 {
+		t.Errorf("Size() after reap = %d, want 0", size)
+	}
+	if got := p.Stats().StaleConns; got != 1 {
+		t.Errorf("Stats().StaleConns = %d, want 1", got)
+	}
+}
+
+// TestConnPoolWarmupStopsOnClose is a regression test for warmupIdleConns
+// leaking connections dialed after Close() already drained p.idle.
+func TestConnPoolWarmupStopsOnClose(t *testing.T) {
+	p := newConnPool(connPoolOptions{
+		Dial: func() (net.Conn, error) {
+			// Slow enough that Close() below reliably runs mid-warmup.
+			time.Sleep(20 * time.Millisecond)
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		},
+		MaxSize:      50,
+		MinIdleConns: 50,
+	})
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Give any in-flight warmup dials time to finish and (incorrectly,
+	// if the leak regresses) land in p.idle.
+	time.Sleep(100 * time.Millisecond)
+
+	if size := p.Size(); size != 0 {
+		t.Errorf("Size() after Close = %d, want 0 (warmup leaked conns past Close)", size)
+	}
+	if n := len(p.idle); n != 0 {
+		t.Errorf("len(p.idle) after Close = %d, want 0", n)
+	}
+}
@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	errorReply     = '-'
+	statusReply    = '+'
+	intReply       = ':'
+	bulkReply      = '$'
+	multiBulkReply = '*'
+)
+
+// redisError is a reply read off the wire that started with '-'. Client
+// code type-asserts on it to recognize special error replies such as
+// MOVED/ASK without having to string-match the generic error interface.
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+func readLine(cn *conn) (string, error) {
+	line, err := cn.Rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readReply decodes a single RESP value off cn. Status and bulk replies
+// are returned as string, integers as int64, and multi-bulk replies as
+// []interface{} of the same.
+func readReply(cn *conn) (interface{}, error) {
+	line, err := readLine(cn)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+
+	switch line[0] {
+	case errorReply:
+		return nil, redisError(line[1:])
+	case statusReply:
+		return line[1:], nil
+	case intReply:
+		return strconv.ParseInt(line[1:], 10, 64)
+	case bulkReply:
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(cn.Rd, b); err != nil {
+			return nil, err
+		}
+		return string(b[:n]), nil
+	case multiBulkReply:
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		vals := make([]interface{}, n)
+		for i := 0; i < n; i++ {
+			vals[i], err = readReply(cn)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return vals, nil
+	}
+
+	return nil, fmt.Errorf("redis: can't parse reply: %q", line)
+}
+
+// writeCmd writes args as a RESP multi-bulk command.
+func writeCmd(cn *conn, args ...string) error {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	_, err := cn.Write(buf)
+	return err
+}
@@ -0,0 +1,216 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// RingOptions configures a Ring client.
+type RingOptions struct {
+	// Addrs maps a shard name to the address of the Redis instance
+	// backing it.
+	Addrs map[string]string
+
+	// HeartbeatFrequency is how often shards are PINGed to detect and
+	// recover from outages. Defaults to 500ms.
+	HeartbeatFrequency time.Duration
+
+	PoolSize     int
+	MinIdleConns int
+	MaxConnAge   time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+}
+
+func (opt *RingOptions) heartbeatFrequency() time.Duration {
+	if opt.HeartbeatFrequency <= 0 {
+		return 500 * time.Millisecond
+	}
+	return opt.HeartbeatFrequency
+}
+
+//------------------------------------------------------------------------------
+
+type ringShard struct {
+	name string
+	pool *connPool
+
+	healthy int32 // atomic bool, 1 = in rotation
+}
+
+// Ring shards keys across a fixed set of Redis instances using
+// rendezvous (highest random weight) hashing: for a given key, the
+// shard whose hash(name, key) is largest wins. Unlike modulo hashing,
+// adding or removing a shard only remaps the ~1/N of keys that shard
+// owned, and unhealthy shards are taken out of the rotation without
+// remapping everyone else's keys.
+type Ring struct {
+	opt *RingOptions
+
+	mu     sync.RWMutex
+	shards map[string]*ringShard
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewRing(opt *RingOptions) *Ring {
+	r := &Ring{
+		opt:    opt,
+		shards: make(map[string]*ringShard, len(opt.Addrs)),
+		stop:   make(chan struct{}),
+	}
+
+	for name, addr := range opt.Addrs {
+		r.shards[name] = &ringShard{
+			name:    name,
+			healthy: 1,
+			pool: newConnPool(connPoolOptions{
+				Dial: func(addr string) func() (net.Conn, error) {
+					return func() (net.Conn, error) {
+						return net.DialTimeout("tcp", addr, 5*time.Second)
+					}
+				}(addr),
+				MaxSize:      opt.PoolSize,
+				MinIdleConns: opt.MinIdleConns,
+				ReadTimeout:  opt.ReadTimeout,
+				WriteTimeout: opt.WriteTimeout,
+				IdleTimeout:  opt.IdleTimeout,
+				MaxConnAge:   opt.MaxConnAge,
+			}),
+		}
+	}
+
+	go r.heartbeat()
+
+	return r
+}
+
+// shardFor picks the live shard with the highest hash(shardName, key).
+func (r *Ring) shardFor(key string) *ringShard {
+	key = hashtagKey(key)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var best *ringShard
+	var bestHash uint64
+	for name, s := range r.shards {
+		if atomic.LoadInt32(&s.healthy) == 0 {
+			continue
+		}
+		h := xxhash.Sum64String(name + "\x00" + key)
+		if best == nil || h > bestHash {
+			best, bestHash = s, h
+		}
+	}
+	return best
+}
+
+// Do routes a single command to the shard owning args' key.
+func (r *Ring) Do(args ...string) (interface{}, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("redis: ring command %q requires a key", args)
+	}
+
+	shard := r.shardFor(args[1])
+	if shard == nil {
+		return nil, fmt.Errorf("redis: no healthy ring shards")
+	}
+
+	cn, _, err := shard.pool.Get(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeCmd(cn, args...); err != nil {
+		shard.pool.Remove(cn)
+		return nil, err
+	}
+
+	v, err := readReply(cn)
+	if err != nil {
+		if _, ok := err.(redisError); !ok {
+			shard.pool.Remove(cn)
+			return nil, err
+		}
+	}
+
+	shard.pool.Put(cn)
+	return v, err
+}
+
+// heartbeat PINGs every shard on a timer, marking it out of (or back
+// into) rotation based on whether it answers.
+func (r *Ring) heartbeat() {
+	ticker := time.NewTicker(r.opt.heartbeatFrequency())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.mu.RLock()
+			shards := make([]*ringShard, 0, len(r.shards))
+			for _, s := range r.shards {
+				shards = append(shards, s)
+			}
+			r.mu.RUnlock()
+
+			for _, s := range shards {
+				r.pingShard(s)
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func (r *Ring) pingShard(s *ringShard) {
+	// Bound the wait for a conn so one busy-but-healthy shard can't
+	// stall the heartbeat of every other shard.
+	ctx, cancel := context.WithTimeout(context.Background(), r.opt.heartbeatFrequency())
+	defer cancel()
+
+	cn, _, err := s.pool.Get(ctx)
+	if err != nil {
+		atomic.StoreInt32(&s.healthy, 0)
+		return
+	}
+
+	if err := writeCmd(cn, "PING"); err != nil {
+		s.pool.Remove(cn)
+		atomic.StoreInt32(&s.healthy, 0)
+		return
+	}
+	if _, err := readReply(cn); err != nil {
+		s.pool.Remove(cn)
+		atomic.StoreInt32(&s.healthy, 0)
+		return
+	}
+
+	s.pool.Put(cn)
+	atomic.StoreInt32(&s.healthy, 1)
+}
+
+func (r *Ring) Close() error {
+	var firstErr error
+	r.closeOnce.Do(func() {
+		close(r.stop)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for _, s := range r.shards {
+			if err := s.pool.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	})
+	return firstErr
+}
@@ -0,0 +1,80 @@
+package redis
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func newTestRing(shardNames ...string) *Ring {
+	addrs := make(map[string]string, len(shardNames))
+	for _, name := range shardNames {
+		addrs[name] = name + ":6379"
+	}
+	return NewRing(&RingOptions{Addrs: addrs})
+}
+
+func TestRingShardForIsStable(t *testing.T) {
+	r := newTestRing("shard1", "shard2", "shard3")
+	defer r.Close()
+
+	first := r.shardFor("somekey")
+	for i := 0; i < 100; i++ {
+		if got := r.shardFor("somekey"); got.name != first.name {
+			t.Fatalf("shardFor(%q) returned %q on call %d, want stable %q", "somekey", got.name, i, first.name)
+		}
+	}
+}
+
+func TestRingShardForHonorsHashtag(t *testing.T) {
+	r := newTestRing("shard1", "shard2", "shard3")
+	defer r.Close()
+
+	a := r.shardFor("{user1000}.following")
+	b := r.shardFor("{user1000}.followers")
+	if a.name != b.name {
+		t.Errorf("keys sharing a hashtag landed on different shards: %q != %q", a.name, b.name)
+	}
+}
+
+func TestRingShardForSkipsUnhealthy(t *testing.T) {
+	r := newTestRing("shard1", "shard2")
+	defer r.Close()
+
+	for _, s := range r.shards {
+		atomic.StoreInt32(&s.healthy, 0)
+	}
+	r.shards["shard1"].healthy = 1
+
+	for i := 0; i < 20; i++ {
+		if got := r.shardFor("key"); got.name != "shard1" {
+			t.Fatalf("shardFor returned %q, want the only healthy shard shard1", got.name)
+		}
+	}
+}
+
+func TestRingShardForNoHealthyShards(t *testing.T) {
+	r := newTestRing("shard1")
+	defer r.Close()
+
+	for _, s := range r.shards {
+		atomic.StoreInt32(&s.healthy, 0)
+	}
+
+	if got := r.shardFor("key"); got != nil {
+		t.Errorf("shardFor with no healthy shards = %v, want nil", got)
+	}
+}
+
+func TestRingShardForDistributesKeys(t *testing.T) {
+	r := newTestRing("shard1", "shard2", "shard3")
+	defer r.Close()
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		s := r.shardFor(string(rune('a'+i%26)) + string(rune(i)))
+		counts[s.name]++
+	}
+	if len(counts) < 2 {
+		t.Errorf("got keys landing on only %d distinct shards out of 3, want more spread", len(counts))
+	}
+}
@@ -0,0 +1,431 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FailoverOptions configures a FailoverClient: a client that resolves
+// its master address through Sentinel instead of connecting to a fixed
+// address.
+type FailoverOptions struct {
+	MasterName    string
+	SentinelAddrs []string
+
+	Password string
+	DB       int64
+
+	PoolSize     int
+	MinIdleConns int
+	MaxConnAge   time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+}
+
+// FailoverClient is a Redis client that connects to a master elected by
+// Sentinel and redials automatically when Sentinel reports a failover.
+type FailoverClient struct {
+	connPool *connPool
+	failover *sentinelFailover
+}
+
+func NewFailoverClient(opt *FailoverOptions) *FailoverClient {
+	failover := &sentinelFailover{
+		masterName:    opt.MasterName,
+		sentinelAddrs: opt.SentinelAddrs,
+		stop:          make(chan struct{}),
+	}
+
+	c := &FailoverClient{failover: failover}
+	c.connPool = newConnPool(connPoolOptions{
+		Dial:         failover.dial,
+		MaxSize:      opt.PoolSize,
+		MinIdleConns: opt.MinIdleConns,
+		ReadTimeout:  opt.ReadTimeout,
+		WriteTimeout: opt.WriteTimeout,
+		IdleTimeout:  opt.IdleTimeout,
+		MaxConnAge:   opt.MaxConnAge,
+	})
+	failover.pool = c.connPool
+
+	failover.listen()
+
+	return c
+}
+
+// Do sends a single command to the current master, redialing through
+// sentinelFailover if the pool has no live connection.
+func (c *FailoverClient) Do(ctx context.Context, args ...string) (interface{}, error) {
+	cn, _, err := c.connPool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeCmd(cn, args...); err != nil {
+		c.connPool.Remove(cn)
+		return nil, err
+	}
+
+	v, err := readReply(cn)
+	if err != nil {
+		if _, ok := err.(redisError); !ok {
+			c.connPool.Remove(cn)
+			return nil, err
+		}
+	}
+
+	c.connPool.Put(cn)
+	return v, err
+}
+
+func (c *FailoverClient) Close() error {
+	c.failover.close()
+	return c.connPool.Close()
+}
+
+//------------------------------------------------------------------------------
+
+// sentinelFailover discovers and tracks the current master address for
+// masterName through a set of Sentinels, and flushes pool on every
+// +switch-master event so subsequent Get()s redial the new master.
+type sentinelFailover struct {
+	masterName    string
+	sentinelAddrs []string
+
+	pool *connPool
+
+	mu sync.RWMutex
+	// resolvedAddr is the last-known master address. It's queried from
+	// Sentinel only once, lazily; after that it's kept current by
+	// listenOnce's +switch-master subscription, so a busy pool dialing
+	// many conns at once doesn't hammer Sentinel or serialize behind a
+	// SENTINEL round trip on every dial.
+	resolvedAddr string
+
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	subMu   sync.Mutex
+	subConn *conn
+}
+
+func (d *sentinelFailover) dial() (net.Conn, error) {
+	addr, err := d.masterAddr()
+	if err != nil {
+		return nil, err
+	}
+	return net.DialTimeout("tcp", addr, 5*time.Second)
+}
+
+// masterAddr returns the cached master address, resolving it from
+// Sentinel only if it isn't known yet.
+func (d *sentinelFailover) masterAddr() (string, error) {
+	d.mu.RLock()
+	addr := d.resolvedAddr
+	d.mu.RUnlock()
+	if addr != "" {
+		return addr, nil
+	}
+	return d.queryMasterAddr()
+}
+
+// queryMasterAddr asks a live sentinel for the current master address,
+// rotating through sentinelAddrs until one answers, and caches it.
+func (d *sentinelFailover) queryMasterAddr() (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.resolvedAddr != "" {
+		// Another goroutine resolved it while we waited for the lock.
+		return d.resolvedAddr, nil
+	}
+
+	for i := 0; i < len(d.sentinelAddrs); i++ {
+		addr := d.sentinelAddrs[i]
+		cn, err := d.dialSentinel(addr)
+		if err != nil {
+			continue
+		}
+
+		if err := writeCmd(cn, "SENTINEL", "get-master-addr-by-name", d.masterName); err != nil {
+			cn.Cn.Close()
+			continue
+		}
+		reply, err := readReply(cn)
+		if err != nil {
+			cn.Cn.Close()
+			continue
+		}
+
+		fields, ok := reply.([]interface{})
+		if !ok || len(fields) != 2 {
+			cn.Cn.Close()
+			continue
+		}
+		host, _ := fields[0].(string)
+		port, _ := fields[1].(string)
+		cn.Cn.Close()
+
+		d.promoteSentinelLocked(addr)
+		d.resolvedAddr = fmt.Sprintf("%s:%s", host, port)
+		return d.resolvedAddr, nil
+	}
+
+	return "", fmt.Errorf("redis: all sentinels are unreachable")
+}
+
+func (d *sentinelFailover) dialSentinel(addr string) (*conn, error) {
+	netcn, err := d.dialSentinelConn(addr)()
+	if err != nil {
+		return nil, err
+	}
+	return newConn(netcn, 5*time.Second, 5*time.Second), nil
+}
+
+func (d *sentinelFailover) dialSentinelConn(addr string) func() (net.Conn, error) {
+	return func() (net.Conn, error) {
+		return net.DialTimeout("tcp", addr, 5*time.Second)
+	}
+}
+
+// promoteSentinelLocked moves a responsive sentinel address to the front
+// of the rotation so it's tried first next time. Callers must hold d.mu.
+func (d *sentinelFailover) promoteSentinelLocked(addr string) {
+	for i, a := range d.sentinelAddrs {
+		if a == addr {
+			d.sentinelAddrs[0], d.sentinelAddrs[i] = d.sentinelAddrs[i], d.sentinelAddrs[0]
+			return
+		}
+	}
+}
+
+// listen subscribes to +switch-master on a live sentinel and, on every
+// failover event, discards the pool's connections so the next Get()
+// dials the new master. Before subscribing, it also adds any sentinel
+// addresses reported by SENTINEL sentinels to sentinelAddrs.
+func (d *sentinelFailover) listen() {
+	go func() {
+		for {
+			select {
+			case <-d.stop:
+				return
+			default:
+			}
+
+			if err := d.listenOnce(); err != nil {
+				select {
+				case <-d.stop:
+					return
+				case <-time.After(time.Second):
+				}
+			}
+		}
+	}()
+}
+
+func (d *sentinelFailover) listenOnce() error {
+	addr, err := d.firstReachableSentinel()
+	if err != nil {
+		return err
+	}
+
+	cn, err := d.dialSentinel(addr)
+	if err != nil {
+		return err
+	}
+
+	// Track the live subscription conn so close() can force it closed
+	// and unblock the readReply loop below.
+	d.subMu.Lock()
+	if d.closed() {
+		d.subMu.Unlock()
+		cn.Cn.Close()
+		return fmt.Errorf("redis: sentinelFailover closed")
+	}
+	d.subConn = cn
+	d.subMu.Unlock()
+	defer func() {
+		d.subMu.Lock()
+		d.subConn = nil
+		d.subMu.Unlock()
+	}()
+
+	// Pin this one connection for the lifetime of the subscription in a
+	// single-conn pool of its own, so it's never handed to an unrelated
+	// caller and is cleanly closed when the subscription ends.
+	backing := newConnPool(connPoolOptions{
+		Dial:         d.dialSentinelConn(addr),
+		MaxSize:      1,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	})
+	backing.queue <- struct{}{}
+	backing.size = 1
+	sentinel := newSingleConnPool(backing, cn, false)
+	defer sentinel.Close()
+
+	// Ask before subscribing: once subscribed, the connection accepts
+	// only pub/sub commands.
+	d.discoverSentinels(cn)
+
+	if err := writeCmd(cn, "SUBSCRIBE", "+switch-master"); err != nil {
+		return err
+	}
+	if _, err := readReply(cn); err != nil {
+		return err
+	}
+
+	for {
+		reply, err := readReply(cn)
+		if err != nil {
+			return err
+		}
+		msg, ok := reply.([]interface{})
+		if !ok || len(msg) < 3 {
+			continue
+		}
+		if kind, _ := msg[0].(string); kind == "message" {
+			payload, ok := msg[2].(string)
+			if !ok {
+				continue
+			}
+			// A Sentinel deployment may monitor other masters too;
+			// only flush the pool when the switch is for ours.
+			addr, ok := parseSwitchMaster(d.masterName, payload)
+			if !ok {
+				continue
+			}
+			d.setMasterAddr(addr)
+			d.flushPool()
+		}
+	}
+}
+
+// parseSwitchMaster extracts the new master address from a
+// +switch-master payload, of the form
+// "<master-name> <old-ip> <old-port> <new-ip> <new-port>". It reports
+// ok=false if the payload is malformed or names a different master.
+func parseSwitchMaster(name, payload string) (addr string, ok bool) {
+	fields := strings.Fields(payload)
+	if len(fields) != 5 || fields[0] != name {
+		return "", false
+	}
+	return fmt.Sprintf("%s:%s", fields[3], fields[4]), true
+}
+
+// setMasterAddr caches addr as the resolved master address so
+// subsequent dials skip the SENTINEL round trip.
+func (d *sentinelFailover) setMasterAddr(addr string) {
+	d.mu.Lock()
+	d.resolvedAddr = addr
+	d.mu.Unlock()
+}
+
+func (d *sentinelFailover) firstReachableSentinel() (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.sentinelAddrs) == 0 {
+		return "", fmt.Errorf("redis: no sentinel addresses configured")
+	}
+	return d.sentinelAddrs[0], nil
+}
+
+// discoverSentinels merges any sentinel addresses learned via
+// SENTINEL sentinels <name> into the rotation.
+func (d *sentinelFailover) discoverSentinels(cn *conn) {
+	if err := writeCmd(cn, "SENTINEL", "sentinels", d.masterName); err != nil {
+		return
+	}
+	reply, err := readReply(cn)
+	if err != nil {
+		return
+	}
+	entries, ok := reply.([]interface{})
+	if !ok {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range entries {
+		fields, ok := e.([]interface{})
+		if !ok {
+			continue
+		}
+		var host, port string
+		for i := 0; i+1 < len(fields); i += 2 {
+			k, _ := fields[i].(string)
+			v, _ := fields[i+1].(string)
+			switch k {
+			case "ip":
+				host = v
+			case "port":
+				port = v
+			}
+		}
+		if host == "" || port == "" {
+			continue
+		}
+		addr := fmt.Sprintf("%s:%s", host, port)
+		if !contains(d.sentinelAddrs, addr) {
+			d.sentinelAddrs = append(d.sentinelAddrs, addr)
+		}
+	}
+}
+
+func contains(addrs []string, addr string) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// flushPool closes every idle pooled connection so the next Get() dials
+// the (by then updated) master. Checked-out conns are left alone; they
+// fail their next command and get Remove()d by their caller.
+func (d *sentinelFailover) flushPool() {
+	p := d.pool
+	for {
+		select {
+		case cn := <-p.idle:
+			p.closeConn(cn)
+			<-p.queue
+			p.mu.Lock()
+			p.size--
+			p.mu.Unlock()
+		default:
+			return
+		}
+	}
+}
+
+func (d *sentinelFailover) closed() bool {
+	select {
+	case <-d.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops listen's retry loop and unblocks a pending readReply on
+// the live subscription conn, if any, so listenOnce returns instead of
+// blocking forever on a closed client.
+func (d *sentinelFailover) close() {
+	d.closeOnce.Do(func() {
+		close(d.stop)
+
+		d.subMu.Lock()
+		if d.subConn != nil {
+			d.subConn.Cn.Close()
+		}
+		d.subMu.Unlock()
+	})
+}
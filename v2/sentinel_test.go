@@ -0,0 +1,81 @@
+package redis
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseSwitchMaster(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		addr    string
+		wantOK  bool
+	}{
+		{"mymaster", "mymaster 127.0.0.1 6379 127.0.0.1 6380", "127.0.0.1:6380", true},
+		{"mymaster", "othermaster 127.0.0.1 6379 127.0.0.1 6380", "", false},
+		{"mymaster", "mymaster 127.0.0.1 6379", "", false},
+		{"mymaster", "", "", false},
+	}
+	for _, tt := range tests {
+		addr, ok := parseSwitchMaster(tt.name, tt.payload)
+		if ok != tt.wantOK || addr != tt.addr {
+			t.Errorf("parseSwitchMaster(%q, %q) = (%q, %v), want (%q, %v)", tt.name, tt.payload, addr, ok, tt.addr, tt.wantOK)
+		}
+	}
+}
+
+func TestSentinelFailoverSetMasterAddr(t *testing.T) {
+	d := &sentinelFailover{stop: make(chan struct{})}
+
+	addr, err := d.masterAddr()
+	if err == nil {
+		t.Fatalf("masterAddr() with no cached address and no sentinels = (%q, nil), want an error", addr)
+	}
+
+	d.setMasterAddr("10.0.0.1:6379")
+
+	addr, err = d.masterAddr()
+	if err != nil {
+		t.Fatalf("masterAddr() after setMasterAddr: %v", err)
+	}
+	if addr != "10.0.0.1:6379" {
+		t.Errorf("masterAddr() = %q, want 10.0.0.1:6379", addr)
+	}
+}
+
+func TestSentinelFailoverFlushPoolDrainsIdleConns(t *testing.T) {
+	p := newConnPool(connPoolOptions{
+		Dial:    newPipeDialer(t),
+		MaxSize: 2,
+	})
+	defer p.Close()
+
+	cn, _, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := p.Put(cn); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := p.Size(); got != 1 {
+		t.Fatalf("Size() before flush = %d, want 1", got)
+	}
+
+	d := &sentinelFailover{pool: p}
+	d.flushPool()
+
+	if got := p.Size(); got != 0 {
+		t.Errorf("Size() after flushPool = %d, want 0", got)
+	}
+	if got := len(p.idle); got != 0 {
+		t.Errorf("len(p.idle) after flushPool = %d, want 0", got)
+	}
+
+	// The pool must still be usable afterward: a fresh Get should dial
+	// a new conn rather than block on a token flushPool failed to
+	// release.
+	if _, _, err := p.Get(context.Background()); err != nil {
+		t.Errorf("Get after flushPool: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package redis
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseURL parses a Redis connection URI into Options. It understands
+// the common "redis://[:password@]host:port[/db]?key=value" form, its
+// TLS variant "rediss://", and "unix:///path/to/socket[?db=N]".
+//
+// Unknown query parameters are rejected so a typo (e.g. "read_timeout"
+// misspelled) is caught at startup instead of silently ignored.
+func ParseURL(rawurl string) (*Options, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Options{}
+
+	switch u.Scheme {
+	case "unix":
+		o.Network = "unix"
+		o.Addr = u.Path
+	case "redis", "rediss":
+		o.Network = "tcp"
+		o.Addr, err = hostWithDefaultPort(u.Host)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme == "rediss" {
+			o.TLSConfig = &tls.Config{ServerName: hostOnly(o.Addr)}
+		}
+	default:
+		return nil, fmt.Errorf("redis: invalid URL scheme: %q", u.Scheme)
+	}
+
+	if u.User != nil {
+		o.Password, _ = u.User.Password()
+	}
+
+	if o.Network == "tcp" {
+		if db, err := dbFromPath(u.Path); err != nil {
+			return nil, err
+		} else {
+			o.DB = db
+		}
+	} else if db := u.Query().Get("db"); db != "" {
+		n, err := strconv.ParseInt(db, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid db %q: %w", db, err)
+		}
+		o.DB = n
+	}
+
+	for param, values := range u.Query() {
+		if param == "db" {
+			continue
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if err := setOption(o, param, values[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
+
+func hostWithDefaultPort(host string) (string, error) {
+	if host == "" {
+		return "", fmt.Errorf("redis: URL has no host")
+	}
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host, nil
+	}
+	return net.JoinHostPort(host, "6379"), nil
+}
+
+func hostOnly(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+func dbFromPath(path string) (int64, error) {
+	path = strings.TrimPrefix(path, "/")
+	if path == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(path, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("redis: invalid db %q in URL path", path)
+	}
+	return n, nil
+}
+
+func setOption(o *Options, param, value string) error {
+	switch param {
+	case "pool_size":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("redis: invalid pool_size %q: %w", value, err)
+		}
+		o.PoolSize = n
+	case "min_idle_conns":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("redis: invalid min_idle_conns %q: %w", value, err)
+		}
+		o.MinIdleConns = n
+	case "max_conn_age":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("redis: invalid max_conn_age %q: %w", value, err)
+		}
+		o.MaxConnAge = d
+	case "dial_timeout":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("redis: invalid dial_timeout %q: %w", value, err)
+		}
+		o.DialTimeout = d
+	case "read_timeout":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("redis: invalid read_timeout %q: %w", value, err)
+		}
+		o.ReadTimeout = d
+	case "write_timeout":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("redis: invalid write_timeout %q: %w", value, err)
+		}
+		o.WriteTimeout = d
+	case "idle_timeout":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("redis: invalid idle_timeout %q: %w", value, err)
+		}
+		o.IdleTimeout = d
+	case "pool_timeout":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("redis: invalid pool_timeout %q: %w", value, err)
+		}
+		o.PoolTimeout = d
+	default:
+		return fmt.Errorf("redis: unknown URL query parameter %q", param)
+	}
+	return nil
+}